@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// addrList is a flag.Value that accumulates multiaddrs from repeated
+// -peer/-listen flags.
+type addrList []multiaddr.Multiaddr
+
+func (al *addrList) String() string {
+	strs := make([]string, len(*al))
+	for i, addr := range *al {
+		strs[i] = addr.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+func (al *addrList) Set(value string) error {
+	addr, err := multiaddr.NewMultiaddr(value)
+	if err != nil {
+		return err
+	}
+	*al = append(*al, addr)
+	return nil
+}
+
+// profileList is a flag.Value that accumulates profile attributes from
+// repeated -profile flags.
+type profileList []string
+
+func (pl *profileList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+func (pl *profileList) Set(value string) error {
+	*pl = append(*pl, value)
+	return nil
+}
+
+// peerIDList is a flag.Value that accumulates peer IDs from repeated
+// -block flags, used to seed Config.Blocklist.
+type peerIDList []peer.ID
+
+func (pl *peerIDList) String() string {
+	strs := make([]string, len(*pl))
+	for i, pid := range *pl {
+		strs[i] = pid.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+func (pl *peerIDList) Set(value string) error {
+	pid, err := peer.IDB58Decode(value)
+	if err != nil {
+		return err
+	}
+	*pl = append(*pl, pid)
+	return nil
+}
+
+// transportList is a flag.Value that accumulates transport names from
+// repeated -transport flags, used to seed Config.Transports.
+type transportList []string
+
+func (tl *transportList) String() string {
+	return strings.Join(*tl, ",")
+}
+
+func (tl *transportList) Set(value string) error {
+	*tl = append(*tl, value)
+	return nil
+}
+
+// defaultListenAddrs builds the dual-stack (IPv4 + IPv6), multi-transport
+// listen multiaddrs for port, one per transport named in transports (any of
+// "tcp", "quic"). It's used when the user hasn't supplied explicit -listen
+// addresses.
+func defaultListenAddrs(port int, transports []string) (addrList, error) {
+	var addrs addrList
+	for _, t := range transports {
+		var formats []string
+		switch t {
+		case "tcp":
+			formats = []string{"/ip4/0.0.0.0/tcp/%d", "/ip6/::/tcp/%d"}
+		case "quic":
+			formats = []string{"/ip4/0.0.0.0/udp/%d/quic", "/ip6/::/udp/%d/quic"}
+		default:
+			continue
+		}
+		for _, format := range formats {
+			addr, err := multiaddr.NewMultiaddr(fmt.Sprintf(format, port))
+			if err != nil {
+				return nil, err
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// Config holds the settings parsed from command-line flags that drive
+// gravitationRendezvous.
+type Config struct {
+	RendezvousString string
+	BootstrapPeers   addrList
+	ListenAddresses  addrList
+	Profile          profileList
+	TestFile         string
+	SaveFile         string
+	LoadFile         string
+	DataStorePath    string
+	ConnMgrLow       int
+	ConnMgrHigh      int
+	ConnMgrGrace     time.Duration
+	Blocklist        peerIDList
+	Port             int
+	Transports       transportList
+	DisableLAN       bool
+}
+
+// ParseFlags parses the command-line flags into a Config.
+func ParseFlags() (Config, error) {
+	config := Config{}
+	flag.StringVar(&config.RendezvousString, "rendezvous", "gravitation", "Unique string to identify the group of nodes to gravitate towards")
+	flag.Var(&config.BootstrapPeers, "peer", "Adds a peer multiaddress to the bootstrap list")
+	flag.Var(&config.ListenAddresses, "listen", "Adds a multiaddress to the listen list")
+	flag.Var(&config.Profile, "profile", "Adds an attribute to this node's profile")
+	flag.StringVar(&config.TestFile, "t", "", "Runs a gravitation protocol test with the given test file")
+	flag.StringVar(&config.SaveFile, "save", "", "File to save gravitation data to on exit")
+	flag.StringVar(&config.LoadFile, "load", "", "File to load gravitation data from on startup")
+	flag.StringVar(&config.DataStorePath, "datastore", "grav-datastore", "Directory for the badger-backed peerstore and orbit datastore")
+	flag.IntVar(&config.ConnMgrLow, "connmgr-low", 64, "Low watermark for the connection manager")
+	flag.IntVar(&config.ConnMgrHigh, "connmgr-high", 128, "High watermark for the connection manager")
+	flag.DurationVar(&config.ConnMgrGrace, "connmgr-grace", time.Minute, "Grace period before the connection manager will prune a new connection")
+	flag.Var(&config.Blocklist, "block", "Adds a peer ID to the inbound connection blocklist")
+	flag.IntVar(&config.Port, "port", 4001, "Port to listen on when no explicit -listen addresses are given")
+	flag.Var(&config.Transports, "transport", "Adds a transport to dial/listen with (tcp, quic); defaults to both")
+	flag.BoolVar(&config.DisableLAN, "disable-lan", false, "Disable the mDNS-seeded LAN DHT and run WAN-only")
+	flag.Parse()
+
+	if len(config.Transports) == 0 {
+		config.Transports = transportList{"tcp", "quic"}
+	}
+
+	if len(config.ListenAddresses) == 0 {
+		listenAddrs, err := defaultListenAddrs(config.Port, config.Transports)
+		if err != nil {
+			return config, err
+		}
+		config.ListenAddresses = listenAddrs
+	}
+
+	return config, nil
+}
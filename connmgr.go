@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+
+	control "github.com/libp2p/go-libp2p-core/control"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// orbitProtectTag is the connmgr protection tag applied to peers currently
+// in orbit, so they survive pruning under connection pressure.
+const orbitProtectTag = "orbit"
+
+// orbitGater is a connmgr.ConnectionGater that rejects inbound connections
+// from peers on a configurable blocklist.
+type orbitGater struct {
+	mu        sync.RWMutex
+	blocklist map[peer.ID]struct{}
+}
+
+// newOrbitGater builds an orbitGater that blocks the given peer IDs.
+func newOrbitGater(blocklist []peer.ID) *orbitGater {
+	g := &orbitGater{blocklist: make(map[peer.ID]struct{}, len(blocklist))}
+	for _, pid := range blocklist {
+		g.blocklist[pid] = struct{}{}
+	}
+	return g
+}
+
+func (g *orbitGater) isBlocked(pid peer.ID) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, blocked := g.blocklist[pid]
+	return blocked
+}
+
+// InterceptPeerDial always allows outbound dials; the blocklist only
+// protects against unwanted inbound connections.
+func (g *orbitGater) InterceptPeerDial(p peer.ID) bool { return true }
+
+// InterceptAddrDial always allows outbound dials.
+func (g *orbitGater) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool { return true }
+
+// InterceptAccept always allows the raw connection through; the peer's
+// identity isn't known yet at this stage, so blocklisted peers are
+// rejected once identity is established in InterceptSecured.
+func (g *orbitGater) InterceptAccept(cm network.ConnMultiaddrs) bool { return true }
+
+// InterceptSecured rejects inbound connections from blocklisted peers once
+// their identity has been established by the security handshake.
+func (g *orbitGater) InterceptSecured(dir network.Direction, p peer.ID, cm network.ConnMultiaddrs) bool {
+	if dir == network.DirInbound && g.isBlocked(p) {
+		return false
+	}
+	return true
+}
+
+// InterceptUpgraded always allows the fully-upgraded connection through.
+func (g *orbitGater) InterceptUpgraded(c network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
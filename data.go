@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	badger "github.com/ipfs/go-ds-badger2"
+)
+
+// Body is a peer that has been pulled into orbit around this node.
+type Body struct {
+	peerID      string
+	Profile     []string
+	LastSeen    time.Time
+	ProfileHash string
+}
+
+// GravitationData is the gravitational state of a node: the profile it
+// advertises and the peers currently in its orbit.
+type GravitationData struct {
+	Profile []string
+	Orbit   []Body
+}
+
+// hashProfile returns a stable hash of a profile, stored alongside each
+// orbit Body so a restart can tell whether a peer's profile has changed
+// without re-gravitating.
+func hashProfile(profile []string) string {
+	h := sha256.Sum256([]byte(strings.Join(profile, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+// newBody builds an orbit Body for peerID and profile, stamped with the
+// current time and profile hash.
+func newBody(peerID string, profile []string) Body {
+	return Body{
+		peerID:      peerID,
+		Profile:     profile,
+		LastSeen:    time.Now(),
+		ProfileHash: hashProfile(profile),
+	}
+}
+
+// WriteGravData persists gravData to the badger datastore rooted at path:
+// the profile under a dedicated key, and every orbit member as a keyed
+// record in the "orbit" namespace. It is a thin wrapper kept so callers
+// that only know a save path don't need to deal with the datastore
+// directly.
+func WriteGravData(path string, gravData *GravitationData) error {
+	ds, err := badger.NewDatastore(path, nil)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+
+	if err := putProfile(ds, gravData.Profile); err != nil {
+		return err
+	}
+
+	store := newOrbitStore(ds)
+	for _, b := range gravData.Orbit {
+		if err := store.Put(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadGravData loads gravData from the badger datastore rooted at path,
+// recovering the saved profile and orbit membership (with last-seen
+// timestamps and profile hashes) without re-gravitating.
+func ReadGravData(path string, gravData *GravitationData) error {
+	ds, err := badger.NewDatastore(path, nil)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+
+	profile, err := getProfile(ds)
+	if err != nil {
+		return err
+	}
+	gravData.Profile = profile
+
+	orbit, err := newOrbitStore(ds).Load()
+	if err != nil {
+		return err
+	}
+	gravData.Orbit = orbit
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	discovery "github.com/libp2p/go-libp2p-discovery"
+)
+
+const (
+	// initialAdvertisementTimeout bounds the very first advertise, so a
+	// node that can't reach the DHT yet doesn't block startup.
+	initialAdvertisementTimeout = time.Millisecond
+	// tryAdvertiseTimeout bounds every advertise after the first.
+	tryAdvertiseTimeout = time.Minute
+
+	minDiscoverInterval = 10 * time.Second
+	maxDiscoverInterval = 10 * time.Minute
+
+	seenPeersSize = 256
+)
+
+// setupDiscovery remembers the routing discovery and rendezvous string a
+// node should use for its background advertise/discover loop, so Discover
+// can be called with just a context.
+func (n *Node) setupDiscovery(rd *discovery.RoutingDiscovery, rendezvous string) {
+	n.rd = rd
+	n.rendezvous = rendezvous
+}
+
+// Discover starts the background advertise/discover loop (if it isn't
+// already running) and returns a channel of newly-discovered peers, for
+// other subsystems to consume without duplicating the loop themselves.
+func (n *Node) Discover(ctx context.Context) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, 32)
+	go n.discoverLoop(ctx, out)
+	return out
+}
+
+// discoverLoop re-advertises on the ttl returned by Advertise, and
+// re-queries FindPeers on a jittered, exponentially backed-off interval, so
+// discovery keeps running instead of going quiet once the first FindPeers
+// channel drains. Peers already seen are skipped so they aren't re-dialed
+// every cycle.
+func (n *Node) discoverLoop(ctx context.Context, out chan<- peer.AddrInfo) {
+	seen, _ := lru.New(seenPeersSize)
+
+	go n.advertiseLoop(ctx)
+
+	interval := minDiscoverInterval
+	for {
+		peerChan, err := n.rd.FindPeers(ctx, n.rendezvous)
+		if err != nil {
+			log.Println("discoverLoop: find peers failed:", err)
+		} else {
+			for p := range peerChan {
+				if p.ID == n.ID() || seen.Contains(p.ID) {
+					continue
+				}
+				seen.Add(p.ID, struct{}{})
+
+				select {
+				case out <- p:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(interval)/2 + 1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval + jitter):
+		}
+
+		if interval *= 2; interval > maxDiscoverInterval {
+			interval = maxDiscoverInterval
+		}
+	}
+}
+
+// advertiseLoop re-advertises this node under n.rendezvous, respecting the
+// ttl Advertise returns before re-advertising again.
+func (n *Node) advertiseLoop(ctx context.Context) {
+	timeout := initialAdvertisementTimeout
+
+	for {
+		actx, cancel := context.WithTimeout(ctx, timeout)
+		ttl, err := n.rd.Advertise(actx, n.rendezvous)
+		cancel()
+
+		if timeout < tryAdvertiseTimeout {
+			timeout = tryAdvertiseTimeout
+		}
+
+		if err != nil {
+			log.Println("advertiseLoop: advertise failed:", err)
+			ttl = timeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ttl):
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	event "github.com/libp2p/go-libp2p-core/event"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// identifyBackoff is how long a peer that failed identification is held
+// back from being considered for gravitation again.
+const identifyBackoff = 30 * time.Second
+
+// identifyGate tracks peers that have recently failed identification and
+// should be skipped for a backoff window, mirroring the admission pattern
+// go-libp2p-kad-dht uses for its routing table. It also tracks which peers
+// are actual rendezvous candidates, so an identify event fired for some
+// unrelated peer the host happens to identify (a DHT routing-table peer, a
+// bootstrap peer, a gossipsub mesh peer) doesn't trigger a gravitation
+// attempt.
+type identifyGate struct {
+	mu         sync.Mutex
+	backoff    map[peer.ID]time.Time
+	candidates map[peer.ID]struct{}
+}
+
+func newIdentifyGate() *identifyGate {
+	return &identifyGate{
+		backoff:    make(map[peer.ID]time.Time),
+		candidates: make(map[peer.ID]struct{}),
+	}
+}
+
+// blocked reports whether pid is still within its identification-failure
+// backoff window.
+func (g *identifyGate) blocked(pid peer.ID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until, ok := g.backoff[pid]
+	return ok && time.Now().Before(until)
+}
+
+// fail puts pid into its backoff window.
+func (g *identifyGate) fail(pid peer.ID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.backoff[pid] = time.Now().Add(identifyBackoff)
+}
+
+// addCandidate marks pid as a peer surfaced by rendezvous discovery, so a
+// later identify event for it is allowed to trigger gravitation.
+func (g *identifyGate) addCandidate(pid peer.ID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.candidates[pid] = struct{}{}
+}
+
+// candidate reports whether pid was surfaced by rendezvous discovery,
+// consuming the marker so each candidate is only acted on once.
+func (g *identifyGate) candidate(pid peer.ID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.candidates[pid]; !ok {
+		return false
+	}
+	delete(g.candidates, pid)
+	return true
+}
+
+// identifySignals returns the identify-derived protocols and agent version
+// the peerstore has on record for pid, for use as additional gravitation
+// matching signal alongside the peer's exchanged Profile.
+func (n *Node) identifySignals(pid peer.ID) []string {
+	var signals []string
+
+	if protocols, err := n.Peerstore().GetProtocols(pid); err == nil {
+		signals = append(signals, protocols...)
+	}
+
+	if av, err := n.Peerstore().Get(pid, "AgentVersion"); err == nil {
+		if s, ok := av.(string); ok {
+			signals = append(signals, s)
+		}
+	}
+
+	return signals
+}
+
+// watchIdentifyEvents subscribes to the host's identify events and returns
+// a channel of peer IDs that have completed identification and are ready
+// to be evaluated for gravitation, along with the gate that tracks
+// identification failures. The channel and the subscription are closed
+// when ctx is done.
+func (n *Node) watchIdentifyEvents(ctx context.Context) (<-chan peer.ID, *identifyGate, error) {
+	sub, err := n.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerIdentificationFailed),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gate := newIdentifyGate()
+	ready := make(chan peer.ID, 32)
+
+	go func() {
+		defer sub.Close()
+		defer close(ready)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+
+				switch e := evt.(type) {
+				case event.EvtPeerIdentificationCompleted:
+					select {
+					case ready <- e.Peer:
+					default:
+						log.Println("watchIdentifyEvents: ready channel full, dropping", e.Peer)
+					}
+				case event.EvtPeerIdentificationFailed:
+					gate.fail(e.Peer)
+				}
+			}
+		}
+	}()
+
+	return ready, gate, nil
+}
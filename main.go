@@ -13,19 +13,34 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	badger "github.com/ipfs/go-ds-badger2"
 	libp2p "github.com/libp2p/go-libp2p"
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
+	routing "github.com/libp2p/go-libp2p-core/routing"
 	crypto "github.com/libp2p/go-libp2p-crypto"
 	discovery "github.com/libp2p/go-libp2p-discovery"
 	libp2pdht "github.com/libp2p/go-libp2p-kad-dht"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+	peer "github.com/libp2p/go-libp2p-peer"
 	peerstore "github.com/libp2p/go-libp2p-peerstore"
 	ps "github.com/libp2p/go-libp2p-peerstore"
+	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
+	libp2ptls "github.com/libp2p/go-libp2p-tls"
+	tcp "github.com/libp2p/go-tcp-transport"
 	ma "github.com/multiformats/go-multiaddr"
 	multiaddr "github.com/multiformats/go-multiaddr"
 )
 
+// activeNode holds the live *Node once gravitationRendezvous has
+// constructed one, so the SIGTERM handler in main can save gravitational
+// data through Node's own locking instead of racing the background
+// goroutines that mutate gravData.Orbit.
+var activeNode atomic.Value // stores *Node
+
 // helper method - create a lib-p2p host to listen on a port
 func makeRandomNode(port int, done chan bool, gravData *GravitationData) *Node {
 	// Ignoring most errors for brevity
@@ -127,33 +142,117 @@ func testGravitation(fname string) bool {
 	return reflect.DeepEqual(actualOrbitIds, orbitPeerIds)
 }
 
-func gravitationRendezvous(config Config, gravData *GravitationData) { //profile []string, orbit []Body) {
-	done := make(chan bool, 1)
+// transportOpts translates the configured transport names into the libp2p
+// options that enable them. "quic" brings in the QUIC transport, which
+// carries its own TLS 1.3 handshake; "tcp" brings in the plain TCP
+// transport secured with libp2p-tls, matching the dual-stack listen
+// addresses produced by defaultListenAddrs.
+func transportOpts(transports []string) []libp2p.Option {
+	var opts []libp2p.Option
+	for _, t := range transports {
+		switch t {
+		case "tcp":
+			opts = append(opts,
+				libp2p.Transport(tcp.NewTCPTransport),
+				libp2p.Security(libp2ptls.ID, libp2ptls.New),
+			)
+		case "quic":
+			opts = append(opts, libp2p.Transport(libp2pquic.NewTransport))
+		}
+	}
+	return opts
+}
 
+func gravitationRendezvous(config Config, gravData *GravitationData) { //profile []string, orbit []Body) {
 	ctx := context.Background()
 
 	// libp2p.New constructs a new libp2p Host. Other options can be added
 	// here.
 	priv, _, _ := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
 
-	host, err := libp2p.New(
-		ctx,
+	// Open the badger-backed datastore that will back both the peerstore
+	// and the persisted orbit namespace, so restarts recover peer
+	// addresses and orbit membership without re-gravitating.
+	ds, err := badger.NewDatastore(config.DataStorePath, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	pstore, err := openDataStore(ctx, ds)
+	if err != nil {
+		panic(err)
+	}
+
+	gater := newOrbitGater(config.Blocklist)
+
+	opts := []libp2p.Option{
 		libp2p.ListenAddrs([]multiaddr.Multiaddr(config.ListenAddresses)...),
 		libp2p.Identity(priv),
+		libp2p.Peerstore(pstore),
+		libp2p.ConnectionManager(connmgr.NewConnManager(config.ConnMgrLow, config.ConnMgrHigh, config.ConnMgrGrace)),
+		libp2p.ConnectionGater(gater),
 		libp2p.DisableRelay(),
-	)
+	}
+	opts = append(opts, transportOpts(config.Transports)...)
+
+	host, err := libp2p.New(ctx, opts...)
 	if err != nil {
 		panic(err)
 	}
 
-	node := NewNode(host, done, gravData)
+	// No done channel: nothing in this long-running flow drains it, and
+	// gravitate() signals it on every call (success or failure), so reusing
+	// it here would wedge the first goroutine to call gravitate a second
+	// time. testGravitation is the only caller that needs it.
+	node := NewNode(host, nil, gravData)
+	node.store = newOrbitStore(ds)
+	node.cmgr = host.ConnManager()
+	activeNode.Store(node)
+
+	// Recover orbit membership from the same datastore backing -datastore,
+	// so a restart doesn't have to re-gravitate. -load/-save are a separate,
+	// explicit snapshot path and take precedence when given.
+	if config.LoadFile == "" {
+		if orbit, err := node.store.Load(); err != nil {
+			log.Println("Failed to load persisted orbit:", err)
+		} else {
+			gravData.Orbit = orbit
+		}
+	}
+
+	// Protect peers already in orbit from connmgr pruning.
+	for _, b := range gravData.Orbit {
+		if pid, err := peer.IDB58Decode(b.peerID); err == nil {
+			node.cmgr.Protect(pid, orbitProtectTag)
+		}
+	}
+
+	// Join the profile gossip topic so orbit membership can stay fresh as
+	// peers' profiles mutate, without waiting on a direct Gravitation dial.
+	// The topic is joined now, but the first Publish is deferred until we
+	// have at least one identified peer (below): publishing before that
+	// reaches nobody, since GossipSub has no mesh to flood to yet.
+	if err := node.setupProfileGossip(ctx, config.RendezvousString); err != nil {
+		log.Println("Failed to set up profile gossip:", err)
+	}
 
 	// ----------------------------
 	// Start a DHT, for use in peer discovery. We can't just make a new DHT
 	// client because we want each peer to maintain its own local copy of the
 	// DHT, so that the bootstrapping node of the DHT can go down without
 	// inhibiting future peer discovery.
-	kademliaDHT, err := libp2pdht.New(ctx, host)
+	//
+	// By default this is a dual WAN+LAN DHT: the WAN side behaves as
+	// before, querying the public bootstrap peers, while the LAN side is
+	// seeded by mDNS and private-CIDR peers so that orbit discovery on a
+	// home/office subnet doesn't need a public bootstrap round-trip.
+	// -disable-lan falls back to a single WAN-only DHT.
+	var kademliaDHT routing.Routing
+	if config.DisableLAN {
+		kademliaDHT, err = libp2pdht.New(ctx, host)
+	} else {
+		kademliaDHT, err = dual.New(ctx, host)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -184,28 +283,68 @@ func gravitationRendezvous(config Config, gravData *GravitationData) { //profile
 
 	// We use a rendezvous point "meet me here" to announce our location.
 	// This is like telling your friends to meet you at the Eiffel Tower.
-	log.Println("Announcing ourselves...")
+	// Rather than advertise and search once, keep re-advertising on the
+	// returned ttl and keep re-querying on a backed-off interval, so the
+	// node stays discoverable and discovering for its whole lifetime.
 	routingDiscovery := discovery.NewRoutingDiscovery(kademliaDHT)
-	discovery.Advertise(ctx, routingDiscovery, config.RendezvousString)
-	log.Println("Successfully announced!")
-
-	// Now, look for others who have announced
-	// This is like your friend telling you the location to meet you.
-	log.Println("Searching for other peers...")
-	peerChan, err := routingDiscovery.FindPeers(ctx, config.RendezvousString)
+	node.setupDiscovery(routingDiscovery, config.RendezvousString)
+	peerChan := node.Discover(ctx)
+
+	// Gate gravitation on identify completing, instead of dialing straight
+	// into Gravitation, so orbit decisions can use the peer's identified
+	// protocols and agent version as an extra matching signal and we never
+	// gravitate to a half-connected peer. Gravitation only fires for peers
+	// marked as rendezvous candidates below, not every peer the host
+	// happens to identify (DHT routing-table peers, bootstrap peers,
+	// gossipsub mesh peers, ...).
+	identified, identifyGate, err := node.watchIdentifyEvents(ctx)
 	if err != nil {
 		panic(err)
 	}
 
+	// Seed the LAN side of the dual DHT with mDNS, so same-subnet peers are
+	// found and connected without a public bootstrap round-trip.
+	if !config.DisableLAN {
+		if err := node.setupMDNS(ctx, identifyGate); err != nil {
+			log.Println("Failed to set up mDNS:", err)
+		}
+	}
+
+	go func() {
+		for pid := range identified {
+			// Re-publish our profile on every identified peer, not just
+			// candidates: it's the only thing that gives a newly-identified
+			// peer a mesh to receive it over, and it doubles as the
+			// republish trigger if the profile is ever changed at runtime.
+			if err := node.Publish(gravData.Profile); err != nil {
+				log.Println("Failed to publish profile:", err)
+			}
+
+			if identifyGate.blocked(pid) || !identifyGate.candidate(pid) {
+				continue
+			}
+			log.Println("Identified peer, gravitating:", pid)
+			node.GravitationPeerID(pid)
+		}
+	}()
+
 	for peer := range peerChan {
 		if peer.ID == host.ID() {
 			continue
 		}
+		if identifyGate.blocked(peer.ID) {
+			log.Println("Skipping peer in identify backoff:", peer.ID)
+			continue
+		}
 		log.Println("Found peer:", peer)
 
+		identifyGate.addCandidate(peer.ID)
+
 		log.Println("Connecting to:", peer)
-		node.GravitationPeerID(peer.ID)
-		log.Println("Connected to:", peer)
+		if err := host.Connect(ctx, peer); err != nil {
+			log.Println("Failed to connect:", err)
+			continue
+		}
 	}
 
 	select {}
@@ -241,7 +380,13 @@ func main() {
 
 			if config.SaveFile != "" {
 				log.Printf("Saving data to file: %s", config.SaveFile)
-				WriteGravData(config.SaveFile, &gravData)
+				if n, ok := activeNode.Load().(*Node); ok {
+					if err := n.Save(config.SaveFile); err != nil {
+						log.Println("Failed to save gravitation data:", err)
+					}
+				} else {
+					WriteGravData(config.SaveFile, &gravData)
+				}
 			}
 
 			// Cleanup GC
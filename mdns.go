@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	host "github.com/libp2p/go-libp2p-host"
+	discovery "github.com/libp2p/go-libp2p/p2p/discovery"
+)
+
+// mdnsServiceTag identifies this protocol's peers to mDNS, separate from
+// the rendezvous string used for DHT discovery.
+const mdnsServiceTag = "grav-mdns"
+
+// mdnsInterval is how often the mDNS service re-announces and re-queries.
+const mdnsInterval = time.Minute
+
+// setupMDNS starts an mDNS discovery service on n's host, seeding the LAN
+// DHT with same-subnet peers that don't need a bootstrap round-trip to
+// find. Peers it finds are connected directly and marked as rendezvous
+// candidates so they're eligible for gravitation once identified.
+func (n *Node) setupMDNS(ctx context.Context, gate *identifyGate) error {
+	svc, err := discovery.NewMdnsService(ctx, n.Host, mdnsInterval, mdnsServiceTag)
+	if err != nil {
+		return err
+	}
+
+	svc.RegisterNotifee(&mdnsNotifee{ctx: ctx, host: n.Host, gate: gate})
+	return nil
+}
+
+// mdnsNotifee connects to peers mDNS finds on the local network and marks
+// them as rendezvous candidates.
+type mdnsNotifee struct {
+	ctx  context.Context
+	host host.Host
+	gate *identifyGate
+}
+
+// HandlePeerFound implements discovery.Notifee.
+func (m *mdnsNotifee) HandlePeerFound(pi peer.AddrInfo) {
+	if pi.ID == m.host.ID() || m.gate.blocked(pi.ID) {
+		return
+	}
+
+	m.gate.addCandidate(pi.ID)
+
+	log.Println("mDNS found peer:", pi)
+	if err := m.host.Connect(m.ctx, pi); err != nil {
+		log.Println("mDNS: failed to connect to discovered peer:", err)
+	}
+}
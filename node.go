@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	connmgr "github.com/libp2p/go-libp2p-core/connmgr"
+	discovery "github.com/libp2p/go-libp2p-discovery"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// GravitationProtocol is the protocol ID used for the Gravitation handshake
+// between two peers comparing profiles.
+const GravitationProtocol = "/grav/1.0.0"
+
+// Node wraps a libp2p host with the gravitational state (profile and orbit)
+// that drives peer matching.
+type Node struct {
+	host.Host
+	done     chan bool
+	gravData *GravitationData
+	store    *orbitStore
+	cmgr     connmgr.ConnManager
+
+	rd         *discovery.RoutingDiscovery
+	rendezvous string
+
+	psTopic *pubsub.Topic
+	psSub   *pubsub.Subscription
+
+	// mu guards gravData.Orbit and profileSubs, both of which are now
+	// mutated and read from multiple concurrently-running goroutines
+	// (gossip consumption, identify-triggered gravitation, and concurrent
+	// inbound Gravitation streams).
+	mu          sync.Mutex
+	profileSubs []chan ProfileUpdate
+}
+
+// NewNode wires up the Gravitation stream handler on h and returns a Node
+// ready to gravitate towards other peers.
+func NewNode(h host.Host, done chan bool, gravData *GravitationData) *Node {
+	node := &Node{Host: h, done: done, gravData: gravData}
+	h.SetStreamHandler(GravitationProtocol, node.handleGravitation)
+	return node
+}
+
+// Gravitation opens a Gravitation stream to peerHost and exchanges profiles
+// with it, pulling peerHost into orbit if the profiles match.
+func (n *Node) Gravitation(peerHost host.Host) {
+	s, err := n.NewStream(context.Background(), peerHost.ID(), GravitationProtocol)
+	if err != nil {
+		log.Println("Gravitation: failed to open stream:", err)
+		n.signalDone()
+		return
+	}
+	n.gravitate(s)
+}
+
+// GravitationPeerID opens a Gravitation stream to an already-known peer ID
+// and exchanges profiles with it, pulling the peer into orbit if the
+// profiles match.
+func (n *Node) GravitationPeerID(pid peer.ID) {
+	s, err := n.NewStream(context.Background(), pid, GravitationProtocol)
+	if err != nil {
+		log.Println("GravitationPeerID: failed to open stream:", err)
+		return
+	}
+	n.gravitate(s)
+}
+
+// handleGravitation is the stream handler for incoming Gravitation requests.
+func (n *Node) handleGravitation(s inet.Stream) {
+	n.gravitate(s)
+}
+
+// gravitate exchanges this node's profile with the peer on the other end of
+// s, and if the profiles overlap, adds the peer to the orbit.
+func (n *Node) gravitate(s inet.Stream) {
+	defer s.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(s), bufio.NewWriter(s))
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(n.gravData.Profile); err != nil {
+		log.Println("gravitate: failed to send profile:", err)
+		n.signalDone()
+		return
+	}
+	rw.Flush()
+
+	var peerProfile []string
+	dec := json.NewDecoder(rw)
+	if err := dec.Decode(&peerProfile); err != nil {
+		log.Println("gravitate: failed to read profile:", err)
+		n.signalDone()
+		return
+	}
+
+	// The peer's identified protocols and agent version (populated once
+	// identify has completed for it) count as additional matching signal
+	// alongside its exchanged Profile.
+	remotePeer := s.Conn().RemotePeer()
+	matchAgainst := append(append([]string{}, peerProfile...), n.identifySignals(remotePeer)...)
+
+	if profilesMatch(n.gravData.Profile, matchAgainst) {
+		n.addToOrbit(newBody(remotePeer.String(), peerProfile))
+	}
+
+	n.signalDone()
+}
+
+// signalDone reports a finished gravitation attempt on n.done, for callers
+// (namely testGravitation) that synchronize on it. Production callers run
+// with a nil done channel, since nothing in the long-running rendezvous
+// flow drains it and every gravitate call, success or failure, ends here.
+func (n *Node) signalDone() {
+	if n.done != nil {
+		n.done <- true
+	}
+}
+
+// addToOrbit adds b to the in-memory orbit, persists it if a
+// datastore-backed orbitStore is attached, and tags it as protected in the
+// connection manager so it survives pruning under connection pressure. If
+// b.peerID is already in orbit, the existing record is updated in place
+// (refreshing LastSeen and ProfileHash) instead of appending a duplicate.
+func (n *Node) addToOrbit(b Body) {
+	n.mu.Lock()
+	updated := false
+	for i, existing := range n.gravData.Orbit {
+		if existing.peerID == b.peerID {
+			n.gravData.Orbit[i] = b
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		n.gravData.Orbit = append(n.gravData.Orbit, b)
+	}
+	n.mu.Unlock()
+
+	if n.store != nil {
+		if err := n.store.Put(b); err != nil {
+			log.Println("addToOrbit: failed to persist orbit member:", err)
+		}
+	}
+
+	if n.cmgr != nil {
+		if pid, err := peer.IDB58Decode(b.peerID); err == nil {
+			n.cmgr.Protect(pid, orbitProtectTag)
+		}
+	}
+}
+
+// Save writes a point-in-time snapshot of n's gravitational data to path.
+// It takes mu before reading gravData.Orbit, so it doesn't race the gossip
+// consumer, identify-triggered gravitation, and inbound Gravitation streams
+// that mutate it concurrently.
+func (n *Node) Save(path string) error {
+	n.mu.Lock()
+	snapshot := GravitationData{
+		Profile: n.gravData.Profile,
+		Orbit:   append([]Body(nil), n.gravData.Orbit...),
+	}
+	n.mu.Unlock()
+
+	return WriteGravData(path, &snapshot)
+}
+
+// profilesMatch reports whether two profiles share at least one attribute.
+func profilesMatch(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
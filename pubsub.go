@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// profileTopicPrefix namespaces the GossipSub topic derived from a node's
+// rendezvous string, so profile gossip doesn't collide with other topics
+// peers may be using the same rendezvous for.
+const profileTopicPrefix = "grav/"
+
+// ProfileUpdate is a peer's profile as received over the profile gossip
+// topic.
+type ProfileUpdate struct {
+	PeerID  peer.ID
+	Profile []string
+}
+
+// setupProfileGossip joins the GossipSub topic derived from rendezvous and
+// starts consuming profile updates from it, so orbit membership can be
+// re-evaluated as peers' profiles change without a direct Gravitation
+// round-trip.
+func (n *Node) setupProfileGossip(ctx context.Context, rendezvous string) error {
+	ps, err := pubsub.NewGossipSub(ctx, n.Host)
+	if err != nil {
+		return err
+	}
+
+	topic, err := ps.Join(profileTopicPrefix + rendezvous)
+	if err != nil {
+		return err
+	}
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	n.psTopic = topic
+	n.psSub = sub
+
+	go n.consumeProfileUpdates(ctx)
+
+	return nil
+}
+
+// Publish signs and broadcasts profile to the profile gossip topic.
+func (n *Node) Publish(profile []string) error {
+	if n.psTopic == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	return n.psTopic.Publish(context.Background(), b)
+}
+
+// SubscribeProfiles returns a channel of profile updates gossiped by peers
+// on the profile topic. The channel is closed when ctx is done.
+func (n *Node) SubscribeProfiles(ctx context.Context) <-chan ProfileUpdate {
+	ch := make(chan ProfileUpdate, 32)
+
+	n.mu.Lock()
+	n.profileSubs = append(n.profileSubs, ch)
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// consumeProfileUpdates reads messages off the gossip subscription, fans
+// decoded profiles out to SubscribeProfiles channels, and re-evaluates
+// orbit membership against this node's own profile.
+func (n *Node) consumeProfileUpdates(ctx context.Context) {
+	for {
+		msg, err := n.psSub.Next(ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == n.ID() {
+			continue
+		}
+
+		var profile []string
+		if err := json.Unmarshal(msg.Data, &profile); err != nil {
+			log.Println("consumeProfileUpdates: bad profile message:", err)
+			continue
+		}
+
+		update := ProfileUpdate{PeerID: msg.ReceivedFrom, Profile: profile}
+		n.mu.Lock()
+		subs := make([]chan ProfileUpdate, len(n.profileSubs))
+		copy(subs, n.profileSubs)
+		n.mu.Unlock()
+		for _, sub := range subs {
+			select {
+			case sub <- update:
+			default:
+			}
+		}
+
+		if profilesMatch(n.gravData.Profile, profile) {
+			n.addToOrbit(newBody(msg.ReceivedFrom.String(), profile))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	datastore "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	peerstore "github.com/libp2p/go-libp2p-peerstore"
+	pstoreds "github.com/libp2p/go-libp2p-peerstore/pstoreds"
+)
+
+// orbitNamespaceKey is the datastore key prefix under which orbit Body
+// records are persisted, kept separate from the peerstore's own
+// namespaces in the same underlying datastore.
+var orbitNamespaceKey = datastore.NewKey("orbit")
+
+// profileKey is where this node's own last-published profile is stored.
+var profileKey = datastore.NewKey("profile")
+
+// openDataStore opens the badger-backed datastore at path and builds a
+// libp2p peerstore on top of it, so peer addresses and orbit membership
+// both survive restarts out of the same store.
+func openDataStore(ctx context.Context, ds datastore.Batching) (peerstore.Peerstore, error) {
+	return pstoreds.NewPeerstore(ctx, ds, pstoreds.DefaultOpts())
+}
+
+// orbitStore persists GravitationData.Orbit into a dedicated "orbit"
+// namespace of a datastore, keyed by peer ID, so restarts recover orbit
+// membership without re-gravitating.
+type orbitStore struct {
+	ds datastore.Batching
+}
+
+func newOrbitStore(ds datastore.Batching) *orbitStore {
+	return &orbitStore{ds: namespace.Wrap(ds, orbitNamespaceKey)}
+}
+
+// Put persists b under its peer ID.
+func (o *orbitStore) Put(b Body) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return o.ds.Put(datastore.NewKey(b.peerID), data)
+}
+
+// Load rebuilds an orbit slice from every Body record in the store.
+func (o *orbitStore) Load() ([]Body, error) {
+	results, err := o.ds.Query(dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var orbit []Body
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, entry.Error
+		}
+
+		var b Body
+		if err := json.Unmarshal(entry.Value, &b); err != nil {
+			return nil, err
+		}
+		b.peerID = datastore.RawKey(entry.Key).Name()
+		orbit = append(orbit, b)
+	}
+
+	return orbit, nil
+}
+
+// putProfile stores profile under profileKey.
+func putProfile(ds datastore.Batching, profile []string) error {
+	b, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return ds.Put(profileKey, b)
+}
+
+// getProfile loads the profile stored under profileKey, returning an empty
+// profile if none has been saved yet.
+func getProfile(ds datastore.Batching) ([]string, error) {
+	has, err := ds.Has(profileKey)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+
+	b, err := ds.Get(profileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile []string
+	if err := json.Unmarshal(b, &profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}